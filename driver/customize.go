@@ -0,0 +1,163 @@
+package driver
+
+import (
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// CustomizationNIC describes the guest customization settings for a single
+// virtual NIC, matched to the VM's network adapters by index.
+type CustomizationNIC struct {
+	IPv4Address string // empty: use DHCP
+	IPv4Netmask string
+	IPv6Address string
+	IPv6Netmask int
+
+	Gateway     []string
+	Ipv6Gateway string
+}
+
+// GlobalIPSettings holds the DNS configuration shared by every NIC.
+type GlobalIPSettings struct {
+	DNSServers  []string
+	DNSSuffixes []string
+}
+
+// LinuxOptions configures `LinuxPrep` guest customization.
+type LinuxOptions struct {
+	Hostname   string
+	Domain     string
+	HwClockUTC bool
+	TimeZone   string
+}
+
+// WindowsOptions configures Sysprep guest customization.
+type WindowsOptions struct {
+	ComputerName     string
+	FullName         string
+	OrganizationName string
+	ProductKey       string
+	AdminPassword    string
+	TimeZone         int32
+	AutoLogon        bool
+	AutoLogonCount   int32
+
+	JoinDomain          string
+	DomainAdmin         string
+	DomainAdminPassword string
+	Workgroup           string
+}
+
+// CustomizationConfig describes how to customize a freshly cloned VM's guest
+// identity, equivalent to the "Customize Guest OS" step in the vSphere
+// client.
+type CustomizationConfig struct {
+	LinuxOptions      *LinuxOptions
+	WindowsOptions    *WindowsOptions
+	NetworkInterfaces []CustomizationNIC
+	GlobalIPSettings  GlobalIPSettings
+}
+
+// Customize applies a guest customization spec to the VM, setting hostname,
+// domain, per-NIC IPv4/IPv6 addressing and, for Windows guests, sysprep
+// fields.
+func (vm *VirtualMachine) Customize(spec CustomizationConfig) error {
+	identity, err := spec.identity()
+	if err != nil {
+		return err
+	}
+
+	customSpec := types.CustomizationSpec{
+		Identity: identity,
+		GlobalIPSettings: types.CustomizationGlobalIPSettings{
+			DnsSuffixList: spec.GlobalIPSettings.DNSSuffixes,
+			DnsServerList: spec.GlobalIPSettings.DNSServers,
+		},
+		NicSettingMap: spec.adapterMapping(),
+	}
+
+	task, err := vm.vm.Customize(vm.driver.ctx, customSpec)
+	if err != nil {
+		return err
+	}
+
+	_, err = task.WaitForResult(vm.driver.ctx, nil)
+	return err
+}
+
+func (spec CustomizationConfig) identity() (types.BaseCustomizationIdentitySettings, error) {
+	if spec.WindowsOptions != nil {
+		o := spec.WindowsOptions
+		return &types.CustomizationSysprep{
+			GuiUnattended: types.CustomizationGuiUnattended{
+				Password: &types.CustomizationPassword{
+					Value:     o.AdminPassword,
+					PlainText: true,
+				},
+				TimeZone:       o.TimeZone,
+				AutoLogon:      o.AutoLogon,
+				AutoLogonCount: o.AutoLogonCount,
+			},
+			UserData: types.CustomizationUserData{
+				FullName:     o.FullName,
+				OrgName:      o.OrganizationName,
+				ComputerName: &types.CustomizationFixedName{Name: o.ComputerName},
+				ProductId:    o.ProductKey,
+			},
+			Identification: types.CustomizationIdentification{
+				JoinWorkgroup: o.Workgroup,
+				JoinDomain:    o.JoinDomain,
+				DomainAdmin:   o.DomainAdmin,
+				DomainAdminPassword: &types.CustomizationPassword{
+					Value:     o.DomainAdminPassword,
+					PlainText: true,
+				},
+			},
+		}, nil
+	}
+
+	o := spec.LinuxOptions
+	if o == nil {
+		o = &LinuxOptions{}
+	}
+	return &types.CustomizationLinuxPrep{
+		HostName:   &types.CustomizationFixedName{Name: o.Hostname},
+		Domain:     o.Domain,
+		TimeZone:   o.TimeZone,
+		HwClockUTC: types.NewBool(o.HwClockUTC),
+	}, nil
+}
+
+func (spec CustomizationConfig) adapterMapping() []types.CustomizationAdapterMapping {
+	mapping := make([]types.CustomizationAdapterMapping, 0, len(spec.NetworkInterfaces))
+
+	for _, nic := range spec.NetworkInterfaces {
+		adapter := types.CustomizationIPSettings{
+			Gateway: nic.Gateway,
+		}
+
+		if nic.IPv4Address == "" {
+			adapter.Ip = &types.CustomizationDhcpIpGenerator{}
+		} else {
+			adapter.Ip = &types.CustomizationFixedIp{IpAddress: nic.IPv4Address}
+			adapter.SubnetMask = nic.IPv4Netmask
+		}
+
+		if nic.IPv6Address != "" {
+			adapter.IpV6Spec = &types.CustomizationIPSettingsIpV6AddressSpec{
+				Ip: []types.BaseCustomizationIpV6Generator{
+					&types.CustomizationFixedIpV6{
+						IpAddress:  nic.IPv6Address,
+						SubnetMask: int32(nic.IPv6Netmask),
+					},
+				},
+			}
+			if nic.Ipv6Gateway != "" {
+				adapter.IpV6Spec.Gateway = []string{nic.Ipv6Gateway}
+			}
+		}
+
+		mapping = append(mapping, types.CustomizationAdapterMapping{Adapter: adapter})
+	}
+
+	return mapping
+}
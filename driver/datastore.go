@@ -0,0 +1,127 @@
+package driver
+
+import (
+	"fmt"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// Datastore wraps a concrete govmomi datastore. If pod is set, the
+// datastore has not been chosen yet: it's a placeholder for a datastore
+// cluster (StoragePod) and must be resolved via recommendDatastoreForCreate
+// or recommendDatastoreForClone before it can be used in a create/clone spec.
+type Datastore struct {
+	ds  *object.Datastore
+	pod *object.StoragePod
+
+	driver *Driver
+}
+
+func (d *Driver) FindDatastoreOrDefault(name string) (*Datastore, error) {
+	if name != "" {
+		if pod, err := d.finder.DatastoreCluster(d.ctx, name); err == nil {
+			return &Datastore{pod: pod, driver: d}, nil
+		}
+	}
+
+	ds, err := d.finder.DatastoreOrDefault(d.ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &Datastore{ds: ds, driver: d}, nil
+}
+
+func (ds *Datastore) FileExists(path string) bool {
+	_, err := ds.ds.Stat(ds.driver.ctx, path)
+	return err == nil
+}
+
+func (ds *Datastore) Path(path string) string {
+	return ds.ds.Path(path)
+}
+
+func (ds *Datastore) Name() string {
+	return ds.ds.Name()
+}
+
+// recommendDatastoreForCreate resolves a StoragePod-backed Datastore into a
+// concrete one by asking Storage DRS to recommend placement for a new VM
+// with the given create spec. Plain datastores are returned unchanged.
+func (d *Driver) recommendDatastoreForCreate(ds *Datastore, folder *object.Folder, pool *object.ResourcePool, configSpec types.VirtualMachineConfigSpec) (*Datastore, error) {
+	if ds.pod == nil {
+		return ds, nil
+	}
+
+	podRef := ds.pod.Reference()
+	sps := types.StoragePlacementSpec{
+		Type:         "create",
+		ConfigSpec:   &configSpec,
+		Folder:       types.NewReference(folder.Reference()),
+		ResourcePool: types.NewReference(pool.Reference()),
+		PodSelectionSpec: types.StorageDrsPodSelectionSpec{
+			StoragePod: &podRef,
+		},
+	}
+
+	return d.recommendedDatastore(sps)
+}
+
+// recommendDatastoreForClone is the Clone-path equivalent of
+// recommendDatastoreForCreate.
+func (d *Driver) recommendDatastoreForClone(ds *Datastore, vmRef types.ManagedObjectReference, folder *object.Folder, name string, cloneSpec types.VirtualMachineCloneSpec) (*Datastore, error) {
+	if ds.pod == nil {
+		return ds, nil
+	}
+
+	podRef := ds.pod.Reference()
+	sps := types.StoragePlacementSpec{
+		Type:      "clone",
+		Vm:        &vmRef,
+		CloneSpec: &cloneSpec,
+		CloneName: name,
+		Folder:    types.NewReference(folder.Reference()),
+		PodSelectionSpec: types.StorageDrsPodSelectionSpec{
+			StoragePod: &podRef,
+		},
+	}
+
+	return d.recommendedDatastore(sps)
+}
+
+// recommendedDatastore asks Storage DRS for a placement recommendation and
+// returns the datastore it picked, without applying the recommendation:
+// callers still create/clone the VM themselves via the existing spec, just
+// with this datastore substituted in for the StoragePod.
+func (d *Driver) recommendedDatastore(sps types.StoragePlacementSpec) (*Datastore, error) {
+	srm := object.NewStorageResourceManager(d.client.Client)
+
+	result, err := srm.RecommendDatastores(d.ctx, sps)
+	if err != nil {
+		return nil, err
+	}
+
+	recommendations := result.Recommendations
+	if len(recommendations) == 0 {
+		return nil, fmt.Errorf("Storage DRS returned no placement recommendations for pod %q", dsPodName(sps))
+	}
+
+	if len(recommendations[0].Action) == 0 {
+		return nil, fmt.Errorf("Storage DRS recommendation for pod %q has no actions", dsPodName(sps))
+	}
+
+	action, ok := recommendations[0].Action[0].(*types.StoragePlacementAction)
+	if !ok {
+		return nil, fmt.Errorf("Storage DRS recommendation for pod %q did not contain a storage placement action", dsPodName(sps))
+	}
+
+	ds := object.NewDatastore(d.client.Client, action.Destination)
+	return &Datastore{ds: ds, driver: d}, nil
+}
+
+func dsPodName(sps types.StoragePlacementSpec) string {
+	if sps.PodSelectionSpec.StoragePod == nil {
+		return ""
+	}
+	return sps.PodSelectionSpec.StoragePod.Value
+}
@@ -0,0 +1,77 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// WaitForIP waits for VMware Tools to report a guest IP address and returns
+// the first one matching ipNet (IPv4 or IPv6, whichever family ipNet is in).
+// If ipNet is nil, the first routable non-link-local address is returned
+// instead. Multi-NIC VMs can report
+// several addresses before the one Packer should connect to appears, so
+// WaitForIP keeps waiting until a match shows up, ctx is canceled, or
+// timeout elapses.
+func (vm *VirtualMachine) WaitForIP(ctx context.Context, ipNet *net.IPNet, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	collector := property.DefaultCollector(vm.vm.Client())
+
+	var ip string
+	err := property.Wait(ctx, collector, vm.vm.Reference(), []string{"guest"}, func(changes []types.PropertyChange) bool {
+		for _, change := range changes {
+			if change.Name != "guest" {
+				continue
+			}
+
+			guest, ok := change.Val.(types.GuestInfo)
+			if !ok {
+				continue
+			}
+
+			if addr := firstMatchingIP(guest.Net, ipNet); addr != "" {
+				ip = addr
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return "", err
+	}
+	if ip == "" {
+		// property.Wait returned without the callback above finding a
+		// match (for example, after ctx was canceled).
+		return "", errors.New("no matching guest IP address found")
+	}
+	return ip, nil
+}
+
+func firstMatchingIP(nics []types.GuestNicInfo, ipNet *net.IPNet) string {
+	for _, nic := range nics {
+		for _, addr := range nic.IpAddress {
+			parsed := net.ParseIP(addr)
+			if parsed == nil {
+				continue
+			}
+
+			if ipNet != nil {
+				if ipNet.Contains(parsed) {
+					return addr
+				}
+				continue
+			}
+
+			if parsed.IsGlobalUnicast() {
+				return addr
+			}
+		}
+	}
+	return ""
+}
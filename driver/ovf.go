@@ -0,0 +1,270 @@
+package driver
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/vmware/govmomi/nfc"
+	"github.com/vmware/govmomi/ovf"
+	"github.com/vmware/govmomi/vapi/vcenter"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// OVFNetworkMapping maps a network name found in the OVF descriptor to the
+// name of a network that exists in the target vSphere inventory.
+type OVFNetworkMapping struct {
+	Name    string
+	Network string
+}
+
+type OVFDeployConfig struct {
+	Name         string
+	Folder       string
+	Host         string
+	ResourcePool string
+	Datastore    string
+
+	// Path is the local path to an .ovf or .ova file. For an .ovf, the
+	// referenced disks are read from loose files alongside it. For an
+	// .ova, the descriptor and disks are read directly out of the tar.
+	Path string
+
+	DiskProvisioning string // ex: "thin", "thick", "eagerZeroedThick"
+	NetworkMappings  []OVFNetworkMapping
+
+	PowerOn bool
+}
+
+// DeployOVF imports an OVF/OVA descriptor as a new virtual machine.
+func (d *Driver) DeployOVF(config *OVFDeployConfig) (*VirtualMachine, error) {
+	folder, err := d.FindFolder(config.Folder)
+	if err != nil {
+		return nil, err
+	}
+
+	resourcePool, err := d.FindResourcePool(config.Host, config.ResourcePool)
+	if err != nil {
+		return nil, err
+	}
+
+	host, err := d.FindHost(config.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	datastore, err := d.FindDatastoreOrDefault(config.Datastore)
+	if err != nil {
+		return nil, err
+	}
+	if datastore.pod != nil {
+		// OVF import needs a concrete datastore up front to build the
+		// import spec; there's no create/clone spec here for Storage DRS
+		// to place against, unlike CreateVM/Clone.
+		return nil, fmt.Errorf("%q is a datastore cluster, not a datastore; DeployOVF requires a concrete datastore", config.Datastore)
+	}
+
+	descriptor, err := readOvfDescriptor(config.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	networkMappings := make([]types.OvfNetworkMapping, 0, len(config.NetworkMappings))
+	for _, m := range config.NetworkMappings {
+		network, err := d.finder.Network(d.ctx, m.Network)
+		if err != nil {
+			return nil, err
+		}
+		ref := network.Reference()
+		networkMappings = append(networkMappings, types.OvfNetworkMapping{
+			Name:    m.Name,
+			Network: ref,
+		})
+	}
+
+	manager := ovf.NewManager(d.client.Client)
+	cisp := types.OvfCreateImportSpecParams{
+		EntityName:       config.Name,
+		DiskProvisioning: config.DiskProvisioning,
+		NetworkMapping:   networkMappings,
+	}
+
+	spec, err := manager.CreateImportSpec(d.ctx, descriptor, resourcePool.pool, datastore.ds, cisp)
+	if err != nil {
+		return nil, err
+	}
+	if spec.Error != nil {
+		return nil, errors.New(spec.Error[0].LocalizedMessage)
+	}
+
+	lease, err := resourcePool.pool.ImportVApp(d.ctx, spec.ImportSpec, folder.folder, host.host)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := lease.Wait(d.ctx, spec.FileItem)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uploadOvfDisks(d, lease, info, config.Path); err != nil {
+		lease.Abort(d.ctx, nil)
+		return nil, err
+	}
+
+	if err := lease.Complete(d.ctx); err != nil {
+		return nil, err
+	}
+
+	vm := d.NewVM(&info.Entity)
+
+	if config.PowerOn {
+		if err := vm.PowerOn(); err != nil {
+			return nil, err
+		}
+	}
+
+	return vm, nil
+}
+
+// uploadOvfDisks streams every disk referenced by the import spec, reporting
+// progress via the lease updater. For an .ovf, disks are loose files
+// alongside the descriptor; for an .ova, they're read directly out of the
+// tar.
+func uploadOvfDisks(d *Driver, lease *nfc.Lease, info *nfc.LeaseInfo, sourcePath string) error {
+	updater := lease.StartUpdater(d.ctx, info)
+	defer updater.Done()
+
+	for _, item := range info.Items {
+		if err := uploadOvfDiskItem(d, lease, sourcePath, item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func uploadOvfDiskItem(d *Driver, lease *nfc.Lease, sourcePath string, item nfc.FileItem) error {
+	if isOva(sourcePath) {
+		f, size, err := openOvaMember(sourcePath, item.Path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return lease.Upload(d.ctx, item, f, soap.Upload{ContentLength: size})
+	}
+
+	path := filepath.Join(filepath.Dir(sourcePath), item.Path)
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	return lease.Upload(d.ctx, item, f, soap.Upload{ContentLength: fi.Size()})
+}
+
+func isOva(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".ova")
+}
+
+// readOvfDescriptor returns the OVF XML descriptor for path: the file
+// itself for an .ovf, or the first ".ovf" member of the tar for an .ova.
+func readOvfDescriptor(path string) (string, error) {
+	if !isOva(path) {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return "", fmt.Errorf("%s: no .ovf descriptor found in archive", path)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if strings.EqualFold(filepath.Ext(hdr.Name), ".ovf") {
+			b, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return "", err
+			}
+			return string(b), nil
+		}
+	}
+}
+
+// openOvaMember returns a reader positioned at the named member of the OVA
+// tar, along with its size, for streaming to the import lease.
+func openOvaMember(ovaPath, memberPath string) (io.ReadCloser, int64, error) {
+	f, err := os.Open(ovaPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			f.Close()
+			return nil, 0, fmt.Errorf("%s: member %q not found in archive", ovaPath, memberPath)
+		}
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+
+		if hdr.Name == memberPath || filepath.Base(hdr.Name) == filepath.Base(memberPath) {
+			return tarMember{Reader: tr, f: f}, hdr.Size, nil
+		}
+	}
+}
+
+// tarMember adapts the current entry of a tar.Reader into an io.ReadCloser
+// that closes the underlying file.
+type tarMember struct {
+	io.Reader
+	f *os.File
+}
+
+func (m tarMember) Close() error {
+	return m.f.Close()
+}
+
+// ImportToContentLibrary publishes this VM as a new template item in a
+// vCenter Content Library.
+func (vm *VirtualMachine) ImportToContentLibrary(template vcenter.Template) (string, error) {
+	m := vcenter.NewManager(vm.driver.restClient())
+	template.VMID = vm.vm.Reference().Value
+	return m.CreateTemplate(vm.driver.ctx, template)
+}
+
+// ImportOvfToContentLibrary captures the VM or vApp named by spec.Source as
+// a new OVF template item in a vCenter Content Library and returns the new
+// item's ID.
+func (d *Driver) ImportOvfToContentLibrary(spec vcenter.OVF) (string, error) {
+	m := vcenter.NewManager(d.restClient())
+	return m.CreateOVF(d.ctx, spec)
+}
@@ -0,0 +1,183 @@
+package driver
+
+import (
+	"fmt"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// ControllerConfig describes a single storage controller to attach to a VM.
+// Its position in CreateConfig.DiskControllers controls ordering (and is
+// what DiskConfig.Controller refers to).
+type ControllerConfig struct {
+	Type string // "pvscsi", "lsilogic", "lsilogic-sas", "buslogic", "sata", "nvme"
+}
+
+// DiskConfig describes a single virtual disk to attach to a VM.
+type DiskConfig struct {
+	DiskSizeKB      int64
+	Controller      int    // index into CreateConfig.DiskControllers
+	ThinProvisioned bool
+	EagerlyScrub    bool   // eager-zeroed thick
+	DiskMode        string // "persistent" (def), "independent_persistent", "independent_nonpersistent"
+	Datastore       string // optional: place this disk on a different datastore than the VM home
+}
+
+func (disk DiskConfig) diskMode() string {
+	if disk.DiskMode == "" {
+		return string(types.VirtualDiskModePersistent)
+	}
+	return disk.DiskMode
+}
+
+// addStorage creates the configured controllers and disks and attaches the
+// disks to their controller in order.
+func addStorage(d *Driver, devices object.VirtualDeviceList, config *CreateConfig) (object.VirtualDeviceList, error) {
+	controllerConfigs := config.DiskControllers
+	if len(controllerConfigs) == 0 {
+		controllerConfigs = []ControllerConfig{{Type: "pvscsi"}}
+	}
+
+	controllers := make([]types.BaseVirtualController, len(controllerConfigs))
+	for i, cc := range controllerConfigs {
+		device, err := createController(cc)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+
+		controller, err := devices.FindDiskController(devices.Name(device))
+		if err != nil {
+			return nil, err
+		}
+		controllers[i] = controller
+	}
+
+	disks := config.Disks
+	if len(disks) == 0 {
+		return nil, fmt.Errorf("at least one disk must be configured")
+	}
+
+	for _, disk := range disks {
+		if disk.Controller < 0 || disk.Controller >= len(controllers) {
+			return nil, fmt.Errorf("disk refers to controller %d, but only %d controllers are configured", disk.Controller, len(controllers))
+		}
+
+		backing := &types.VirtualDiskFlatVer2BackingInfo{
+			DiskMode:        disk.diskMode(),
+			ThinProvisioned: types.NewBool(disk.ThinProvisioned),
+			EagerlyScrub:    types.NewBool(disk.EagerlyScrub),
+		}
+
+		if disk.Datastore != "" {
+			ds, err := d.FindDatastoreOrDefault(disk.Datastore)
+			if err != nil {
+				return nil, err
+			}
+			if ds.pod != nil {
+				// Same limitation as DeployOVF: there's no per-disk
+				// create/clone spec to run a Storage DRS recommendation
+				// against here, only a single disk backing.
+				return nil, fmt.Errorf("%q is a datastore cluster, not a datastore; per-disk placement requires a concrete datastore", disk.Datastore)
+			}
+			dsRef := ds.ds.Reference()
+			backing.Datastore = &dsRef
+		}
+
+		device := &types.VirtualDisk{
+			VirtualDevice: types.VirtualDevice{
+				Key:     devices.NewKey(),
+				Backing: backing,
+			},
+			CapacityInKB: disk.DiskSizeKB,
+		}
+
+		devices.AssignController(device, controllers[disk.Controller])
+		devices = append(devices, device)
+	}
+
+	return devices, nil
+}
+
+// createController builds the virtual device for a single storage
+// controller, dispatching to the SCSI, SATA, or NVMe family as appropriate.
+func createController(config ControllerConfig) (types.BaseVirtualDevice, error) {
+	switch config.Type {
+	case "", "pvscsi", "lsilogic", "lsilogic-sas", "buslogic":
+		return object.VirtualDeviceList{}.CreateSCSIController(config.Type)
+	case "sata":
+		return object.VirtualDeviceList{}.CreateSATAController()
+	case "nvme":
+		return object.VirtualDeviceList{}.CreateNVMEController()
+	default:
+		return nil, fmt.Errorf("unknown controller type %q", config.Type)
+	}
+}
+
+// ResizeDisk grows the virtual disk identified by diskKey to newSizeKB.
+func (vm *VirtualMachine) ResizeDisk(diskKey int32, newSizeKB int64) error {
+	devices, err := vm.vm.Device(vm.driver.ctx)
+	if err != nil {
+		return err
+	}
+
+	disk, ok := devices.FindByKey(diskKey).(*types.VirtualDisk)
+	if !ok {
+		return fmt.Errorf("no disk with key %d", diskKey)
+	}
+	disk.CapacityInKB = newSizeKB
+
+	confSpec := types.VirtualMachineConfigSpec{}
+	confSpec.DeviceChange, err = object.VirtualDeviceList{disk}.ConfigSpec(types.VirtualDeviceConfigSpecOperationEdit)
+	if err != nil {
+		return err
+	}
+
+	task, err := vm.vm.Reconfigure(vm.driver.ctx, confSpec)
+	if err != nil {
+		return err
+	}
+	_, err = task.WaitForResult(vm.driver.ctx, nil)
+	return err
+}
+
+// AttachDisk attaches an existing VMDK, identified by its datastore path, to
+// the VM on the given controller.
+func (vm *VirtualMachine) AttachDisk(vmdkPath string, sizeKB int64, controllerKey int32) error {
+	devices, err := vm.vm.Device(vm.driver.ctx)
+	if err != nil {
+		return err
+	}
+
+	controller, ok := devices.FindByKey(controllerKey).(types.BaseVirtualController)
+	if !ok {
+		return fmt.Errorf("no controller with key %d", controllerKey)
+	}
+
+	disk := &types.VirtualDisk{
+		VirtualDevice: types.VirtualDevice{
+			Key: devices.NewKey(),
+			Backing: &types.VirtualDiskFlatVer2BackingInfo{
+				DiskMode: string(types.VirtualDiskModePersistent),
+				FileName: vmdkPath,
+			},
+		},
+		CapacityInKB: sizeKB,
+	}
+	devices.AssignController(disk, controller)
+
+	newDevices := object.VirtualDeviceList{disk}
+	confSpec := types.VirtualMachineConfigSpec{}
+	confSpec.DeviceChange, err = newDevices.ConfigSpec(types.VirtualDeviceConfigSpecOperationAdd)
+	if err != nil {
+		return err
+	}
+
+	task, err := vm.vm.Reconfigure(vm.driver.ctx, confSpec)
+	if err != nil {
+		return err
+	}
+	_, err = task.WaitForResult(vm.driver.ctx, nil)
+	return err
+}
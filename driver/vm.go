@@ -21,26 +21,38 @@ type CloneConfig struct {
 	ResourcePool string
 	Datastore    string
 	LinkedClone  bool
+	NICs         []NIC
 }
 
-type HardwareConfig struct {
-	CPUs           int32
-	CPUReservation int64
-	CPULimit       int64
-	RAM            int64
-	RAMReservation int64
-	RAMReserveAll  bool
+// NIC describes a single virtual network adapter to attach to a VM.
+type NIC struct {
+	Network     string // portgroup or distributed portgroup name
+	NetworkCard string // ex: "vmxnet3", "e1000e"; def: "vmxnet3"
+	MacAddress  string // ex: "00:50:56:00:00:01"; empty: let vSphere generate one
+	Passthrough *bool  // true: attach as an SR-IOV passthrough NIC instead of NetworkCard
 }
 
-type DiskConfig struct {
-	DiskSizeKB      int64
-	ThinProvisioned bool
-	ControllerType  string // ex: "scsi", "pvscsi"
+type HardwareConfig struct {
+	CPUs                int32
+	CPUCores            int32  // maps to NumCoresPerSocket; def: 1 core per socket
+	CPUReservation      int64
+	CPULimit            int64
+	CPUHotAddEnabled    *bool  // nil: leave as-is
+	RAM                 int64
+	RAMReservation      int64
+	RAMReserveAll       bool
+	MemoryHotAddEnabled *bool  // nil: leave as-is
+	NestedHV            *bool  // nil: leave as-is
+	Firmware            string // "bios" (def) or "efi"
+	VideoRAM            int64  // KB; 0: leave the template's default
+	VGPUProfile         string // ex: "grid_p100-4q"; empty: no vGPU
 }
 
 type CreateConfig struct {
 	HardwareConfig
-	DiskConfig
+
+	Disks           []DiskConfig
+	DiskControllers []ControllerConfig
 
 	Annotation   string
 	Name         string
@@ -49,7 +61,7 @@ type CreateConfig struct {
 	ResourcePool string
 	Datastore    string
 	GuestOS      string // def: otherGuest
-	Network      string
+	NICs         []NIC
 	Force        bool
 }
 
@@ -96,22 +108,13 @@ func (d *Driver) CreateVM(config *CreateConfig) (*VirtualMachine, error) {
 		return nil, err
 	}
 
-	// Don't override existing file if parameter "Force" is not specified
-	if !config.Force {
-		vmxPath := fmt.Sprintf("%s/%s.vmx", config.Name, config.Name)
-		if datastore.FileExists(vmxPath) {
-			dsPath := datastore.Path(vmxPath)
-			return nil, fmt.Errorf("File '%v' already exists", dsPath)
-		}
-	}
-
 	devices := object.VirtualDeviceList{}
 
 	devices, err = addCdrom(d, devices, config, datastore)
 	if err != nil {
 		return nil, err
 	}
-	devices, err = addDisk(d, devices, config)
+	devices, err = addStorage(d, devices, config)
 	if err != nil {
 		return nil, err
 	}
@@ -125,6 +128,23 @@ func (d *Driver) CreateVM(config *CreateConfig) (*VirtualMachine, error) {
 		return nil, err
 	}
 
+	// When Datastore names a datastore cluster rather than a plain
+	// datastore, ask Storage DRS to recommend a concrete datastore for this
+	// VM's config spec.
+	datastore, err = d.recommendDatastoreForCreate(datastore, folder.folder, resourcePool.pool, createSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't override existing file if parameter "Force" is not specified
+	if !config.Force {
+		vmxPath := fmt.Sprintf("%s/%s.vmx", config.Name, config.Name)
+		if datastore.FileExists(vmxPath) {
+			dsPath := datastore.Path(vmxPath)
+			return nil, fmt.Errorf("File '%v' already exists", dsPath)
+		}
+	}
+
 	createSpec.Files = &types.VirtualMachineFileInfo{
 		VmPathName: fmt.Sprintf("[%s]", datastore.Name()),
 	}
@@ -177,13 +197,22 @@ func (template *VirtualMachine) Clone(config *CloneConfig) (*VirtualMachine, err
 	if err != nil {
 		return nil, err
 	}
-	datastoreRef := datastore.ds.Reference()
-	relocateSpec.Datastore = &datastoreRef
 
 	var cloneSpec types.VirtualMachineCloneSpec
 	cloneSpec.Location = relocateSpec
 	cloneSpec.PowerOn = false
 
+	// When Datastore names a datastore cluster rather than a plain
+	// datastore, ask Storage DRS to recommend a concrete datastore for this
+	// clone.
+	datastore, err = template.driver.recommendDatastoreForClone(datastore, template.vm.Reference(), folder.folder, config.Name, cloneSpec)
+	if err != nil {
+		return nil, err
+	}
+	datastoreRef := datastore.ds.Reference()
+	relocateSpec.Datastore = &datastoreRef
+	cloneSpec.Location = relocateSpec
+
 	if config.LinkedClone == true {
 		cloneSpec.Location.DiskMoveType = "createNewChildDiskBacking"
 
@@ -198,6 +227,39 @@ func (template *VirtualMachine) Clone(config *CloneConfig) (*VirtualMachine, err
 		cloneSpec.Snapshot = tpl.Snapshot.CurrentSnapshot
 	}
 
+	if len(config.NICs) > 0 {
+		// CloneConfig.NICs replaces the template's network adapters, it
+		// doesn't add to them: remove what the clone would otherwise
+		// inherit before adding the configured set.
+		existing, err := template.vm.Device(template.driver.ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var changes []types.BaseVirtualDeviceConfigSpec
+		for _, nic := range existing.SelectByType((*types.VirtualEthernetCard)(nil)) {
+			changes = append(changes, &types.VirtualDeviceConfigSpec{
+				Operation: types.VirtualDeviceConfigSpecOperationRemove,
+				Device:    nic,
+			})
+		}
+
+		for _, nic := range config.NICs {
+			device, err := createNIC(template.driver, nic)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, &types.VirtualDeviceConfigSpec{
+				Operation: types.VirtualDeviceConfigSpecOperationAdd,
+				Device:    device,
+			})
+		}
+
+		var confSpec types.VirtualMachineConfigSpec
+		confSpec.DeviceChange = changes
+		cloneSpec.Config = &confSpec
+	}
+
 	task, err := template.vm.Clone(template.driver.ctx, folder.folder, config.Name, cloneSpec)
 	if err != nil {
 		return nil, err
@@ -225,6 +287,12 @@ func (vm *VirtualMachine) Destroy() error {
 func (vm *VirtualMachine) Configure(config *HardwareConfig) error {
 	confSpec := config.toConfigSpec()
 
+	deviceChanges, err := vm.hardwareDeviceChanges(config)
+	if err != nil {
+		return err
+	}
+	confSpec.DeviceChange = deviceChanges
+
 	task, err := vm.vm.Reconfigure(vm.driver.ctx, confSpec)
 	if err != nil {
 		return err
@@ -234,6 +302,61 @@ func (vm *VirtualMachine) Configure(config *HardwareConfig) error {
 	return err
 }
 
+// hardwareDeviceChanges diffs the VM's current devices against config and
+// returns the add/edit operations needed to apply VideoRAM and VGPUProfile,
+// which (unlike the rest of HardwareConfig) aren't plain ConfigSpec scalars.
+func (vm *VirtualMachine) hardwareDeviceChanges(config *HardwareConfig) ([]types.BaseVirtualDeviceConfigSpec, error) {
+	if config.VideoRAM == 0 && config.VGPUProfile == "" {
+		return nil, nil
+	}
+
+	devices, err := vm.vm.Device(vm.driver.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []types.BaseVirtualDeviceConfigSpec
+
+	if config.VideoRAM != 0 {
+		l := devices.SelectByType((*types.VirtualMachineVideoCard)(nil))
+		if len(l) == 0 {
+			return nil, errors.New("VM has no video card device to resize")
+		}
+		card := l[0].(*types.VirtualMachineVideoCard)
+		card.VideoRamSizeInKB = config.VideoRAM
+
+		changes = append(changes, &types.VirtualDeviceConfigSpec{
+			Operation: types.VirtualDeviceConfigSpecOperationEdit,
+			Device:    card,
+		})
+	}
+
+	hasVGPU := false
+	for _, d := range devices.SelectByType((*types.VirtualPCIPassthrough)(nil)) {
+		if b, ok := d.GetVirtualDevice().Backing.(*types.VirtualPCIPassthroughVmiopBackingInfo); ok && b.Vgpu == config.VGPUProfile {
+			hasVGPU = true
+		}
+	}
+
+	if config.VGPUProfile != "" && !hasVGPU {
+		device := &types.VirtualPCIPassthrough{
+			VirtualDevice: types.VirtualDevice{
+				Key: devices.NewKey(),
+				Backing: &types.VirtualPCIPassthroughVmiopBackingInfo{
+					Vgpu: config.VGPUProfile,
+				},
+			},
+		}
+
+		changes = append(changes, &types.VirtualDeviceConfigSpec{
+			Operation: types.VirtualDeviceConfigSpecOperationAdd,
+			Device:    device,
+		})
+	}
+
+	return changes, nil
+}
+
 func (vm *VirtualMachine) PowerOn() error {
 	task, err := vm.vm.PowerOn(vm.driver.ctx)
 	if err != nil {
@@ -243,9 +366,6 @@ func (vm *VirtualMachine) PowerOn() error {
 	return err
 }
 
-func (vm *VirtualMachine) WaitForIP() (string, error) {
-	return vm.vm.WaitForIP(vm.driver.ctx)
-}
 
 func (vm *VirtualMachine) PowerOff() error {
 	state, err := vm.vm.PowerState(vm.driver.ctx)
@@ -308,6 +428,7 @@ func (vm *VirtualMachine) ConvertToTemplate() error {
 func (config HardwareConfig) toConfigSpec() types.VirtualMachineConfigSpec {
 	var confSpec types.VirtualMachineConfigSpec
 	confSpec.NumCPUs = config.CPUs
+	confSpec.NumCoresPerSocket = config.CPUCores
 	confSpec.MemoryMB = config.RAM
 
 	var cpuSpec types.ResourceAllocationInfo
@@ -321,6 +442,14 @@ func (config HardwareConfig) toConfigSpec() types.VirtualMachineConfigSpec {
 
 	confSpec.MemoryReservationLockedToMax = &config.RAMReserveAll
 
+	confSpec.CpuHotAddEnabled = config.CPUHotAddEnabled
+	confSpec.MemoryHotAddEnabled = config.MemoryHotAddEnabled
+	confSpec.NestedHVEnabled = config.NestedHV
+
+	if config.Firmware != "" {
+		confSpec.Firmware = config.Firmware
+	}
+
 	return confSpec
 }
 
@@ -332,41 +461,35 @@ func (config CreateConfig) toConfigSpec() types.VirtualMachineConfigSpec {
 	return confSpec
 }
 
-func addDisk(d *Driver, devices object.VirtualDeviceList, config *CreateConfig) (object.VirtualDeviceList, error) {
-	device, err := devices.CreateSCSIController(config.ControllerType)
-	if err != nil {
-		return nil, err
-	}
-	devices = append(devices, device)
-	controller, err := devices.FindDiskController(devices.Name(device))
-	if err != nil {
-		return nil, err
-	}
-
-	if config.DiskSizeKB == 0 {
-		// TODO
-		return nil, fmt.Errorf("not implemented")
+func addNetwork(d *Driver, devices object.VirtualDeviceList, config *CreateConfig) (object.VirtualDeviceList, error) {
+	nics := config.NICs
+	if len(nics) == 0 {
+		nics = []NIC{{}}
 	}
 
-	disk := &types.VirtualDisk{
-		VirtualDevice: types.VirtualDevice{
-			Key: devices.NewKey(),
-			Backing: &types.VirtualDiskFlatVer2BackingInfo{
-				DiskMode:        string(types.VirtualDiskModePersistent), // TODO: should be customizable?
-				ThinProvisioned: types.NewBool(config.ThinProvisioned),
-			},
-		},
-		CapacityInKB: config.DiskSizeKB,
+	for _, nic := range nics {
+		device, err := createNIC(d, nic)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
 	}
 
-	devices.AssignController(disk, controller)
-	devices = append(devices, disk)
-
 	return devices, nil
 }
 
-func addNetwork(d *Driver, devices object.VirtualDeviceList, config *CreateConfig) (object.VirtualDeviceList, error) {
-	network, err := d.finder.NetworkOrDefault(d.ctx, config.Network)
+// createNIC resolves the network named by nic (a standard portgroup or a
+// Distributed Virtual Switch portgroup; finder.Network returns the right
+// backing for either) and creates an ethernet card of the requested type
+// attached to it.
+func createNIC(d *Driver, nic NIC) (types.BaseVirtualDevice, error) {
+	var network object.NetworkReference
+	var err error
+	if nic.Network == "" {
+		network, err = d.finder.DefaultNetwork(d.ctx)
+	} else {
+		network, err = d.finder.Network(d.ctx, nic.Network)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -376,13 +499,26 @@ func addNetwork(d *Driver, devices object.VirtualDeviceList, config *CreateConfi
 		return nil, err
 	}
 
-	device, err := object.EthernetCardTypes().CreateEthernetCard("" /*TODO: Add customization*/, backing)
+	cardType := nic.NetworkCard
+	if cardType == "" {
+		cardType = "vmxnet3"
+	}
+	if nic.Passthrough != nil && *nic.Passthrough {
+		cardType = "sriov"
+	}
+
+	device, err := object.EthernetCardTypes().CreateEthernetCard(cardType, backing)
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO: add address customization
-	return append(devices, device), nil
+	card := device.(types.BaseVirtualEthernetCard).GetVirtualEthernetCard()
+	if nic.MacAddress != "" {
+		card.AddressType = string(types.VirtualEthernetCardMacTypeManual)
+		card.MacAddress = nic.MacAddress
+	}
+
+	return device, nil
 }
 
 func addCdrom(d *Driver, devices object.VirtualDeviceList, config *CreateConfig,